@@ -23,6 +23,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
@@ -30,7 +31,7 @@ import (
 
 // https://docs.datadoghq.com/api/
 const (
-	datadogDefaultHost = "https://api.datadoghq.com"
+	datadogDefaultSite = "datadoghq.com"
 
 	datadogMetricsQueryPath     = "/api/v1/query"
 	datadogAPIKeyValidationPath = "/api/v1/validate"
@@ -41,9 +42,112 @@ const (
 	datadogApplicationKeySecretKey = "datadog_application_key"
 	datadogApplicationKeyHeaderKey = "DD-APPLICATION-KEY"
 
+	// datadogSiteSecretKey is an opt-in credentials/options entry that selects one of
+	// Datadog's regional sites, used to build the API endpoint when Address is not set.
+	datadogSiteSecretKey = "datadog_site"
+
+	// datadogNoDataValueSecretKey is an opt-in credentials/options entry that lets a
+	// MetricTemplate define what RunQuery should return when Datadog has no data for
+	// the queried interval, instead of failing the canary analysis with ErrNoValuesFound.
+	datadogNoDataValueSecretKey = "datadog_no_data_value"
+
+	// datadogMaxRetriesSecretKey and datadogRetryBackoffCapSecretKey are opt-in
+	// credentials/options entries overriding the retry behaviour on 429/5xx responses.
+	datadogMaxRetriesSecretKey      = "datadog_max_retries"
+	datadogRetryBackoffCapSecretKey = "datadog_retry_backoff_cap"
+
+	datadogDefaultMaxRetries      = 3
+	datadogDefaultRetryBackoff    = 500 * time.Millisecond
+	datadogDefaultRetryBackoffCap = 30 * time.Second
+
 	datadogFromDeltaMultiplierOnMetricInterval = 10
 )
 
+// datadogValidSites lists the Datadog sites documented at
+// https://docs.datadoghq.com/getting_started/site/
+var datadogValidSites = map[string]bool{
+	"datadoghq.com":     true,
+	"datadoghq.eu":      true,
+	"us3.datadoghq.com": true,
+	"us5.datadoghq.com": true,
+	"ap1.datadoghq.com": true,
+	"ddog-gov.com":      true,
+}
+
+// datadogHTTPClient is shared by every DatadogProvider instance so that keep-alive
+// connections and TLS sessions are reused across metric templates and canary
+// intervals, instead of dialing a fresh connection per RunQuery/IsOnline call.
+var datadogHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// datadogResolveAddress returns the Datadog API base address to use: provider.Address
+// verbatim if set, otherwise an address built from the datadogSiteSecretKey credential
+// (or datadogDefaultSite), validated against datadogValidSites. Shared by every Datadog
+// provider constructor so site resolution stays consistent across the metrics query and
+// monitor providers.
+func datadogResolveAddress(provider flaggerv1.MetricTemplateProvider, credentials map[string][]byte) (string, error) {
+	if provider.Address != "" {
+		return provider.Address, nil
+	}
+
+	site := datadogDefaultSite
+	if b, ok := credentials[datadogSiteSecretKey]; ok {
+		site = string(b)
+	}
+	if !datadogValidSites[site] {
+		return "", fmt.Errorf("unsupported datadog site %q", site)
+	}
+	return fmt.Sprintf("https://api.%s", site), nil
+}
+
+// datadogAuthFromCredentials extracts the mandatory API and application keys shared by
+// every Datadog provider constructor.
+func datadogAuthFromCredentials(credentials map[string][]byte) (apiKey, applicationKey string, err error) {
+	b, ok := credentials[datadogAPIKeySecretKey]
+	if !ok {
+		return "", "", fmt.Errorf("datadog credentials does not contain datadog_api_key")
+	}
+	apiKey = string(b)
+
+	b, ok = credentials[datadogApplicationKeySecretKey]
+	if !ok {
+		return "", "", fmt.Errorf("datadog credentials does not contain datadog_application_key")
+	}
+	applicationKey = string(b)
+
+	return apiKey, applicationKey, nil
+}
+
+// datadogRetrySettingsFromCredentials returns the max-retries/backoff-cap settings to
+// use, starting from the package defaults and applying the datadogMaxRetriesSecretKey/
+// datadogRetryBackoffCapSecretKey overrides when present. Shared by every Datadog
+// provider constructor so operators can tune retry behaviour consistently.
+func datadogRetrySettingsFromCredentials(credentials map[string][]byte) (maxRetries int, retryBackoffCap time.Duration, err error) {
+	maxRetries = datadogDefaultMaxRetries
+	retryBackoffCap = datadogDefaultRetryBackoffCap
+
+	if b, ok := credentials[datadogMaxRetriesSecretKey]; ok {
+		maxRetries, err = strconv.Atoi(string(b))
+		if err != nil {
+			return 0, 0, fmt.Errorf("error parsing %s: %w", datadogMaxRetriesSecretKey, err)
+		}
+	}
+
+	if b, ok := credentials[datadogRetryBackoffCapSecretKey]; ok {
+		retryBackoffCap, err = time.ParseDuration(string(b))
+		if err != nil {
+			return 0, 0, fmt.Errorf("error parsing %s: %w", datadogRetryBackoffCapSecretKey, err)
+		}
+	}
+
+	return maxRetries, retryBackoffCap, nil
+}
+
 // DatadogProvider executes datadog queries
 type DatadogProvider struct {
 	metricsQueryEndpoint     string
@@ -53,12 +157,21 @@ type DatadogProvider struct {
 	apiKey         string
 	applicationKey string
 	fromDelta      int64
+
+	// noDataValue, when set, is returned by RunQuery instead of ErrNoValuesFound
+	// whenever Datadog reports an empty series or an empty pointlist.
+	noDataValue *float64
+
+	maxRetries      int
+	retryBackoffCap time.Duration
 }
 
 type datadogResponse struct {
 	Series []struct {
 		Pointlist [][]float64 `json:"pointlist"`
 	}
+	Status string   `json:"status"`
+	Errors []string `json:"errors"`
 }
 
 // NewDatadogProvider takes a canary spec, a provider spec and the credentials map, and
@@ -67,27 +180,37 @@ func NewDatadogProvider(metricInterval string,
 	provider flaggerv1.MetricTemplateProvider,
 	credentials map[string][]byte) (*DatadogProvider, error) {
 
-	address := provider.Address
-	if address == "" {
-		address = datadogDefaultHost
+	address, err := datadogResolveAddress(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, applicationKey, err := datadogAuthFromCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries, retryBackoffCap, err := datadogRetrySettingsFromCredentials(credentials)
+	if err != nil {
+		return nil, err
 	}
 
 	dd := DatadogProvider{
 		timeout:                  5 * time.Second,
 		metricsQueryEndpoint:     address + datadogMetricsQueryPath,
 		apiKeyValidationEndpoint: address + datadogAPIKeyValidationPath,
+		apiKey:                   apiKey,
+		applicationKey:           applicationKey,
+		maxRetries:               maxRetries,
+		retryBackoffCap:          retryBackoffCap,
 	}
 
-	if b, ok := credentials[datadogAPIKeySecretKey]; ok {
-		dd.apiKey = string(b)
-	} else {
-		return nil, fmt.Errorf("datadog credentials does not contain datadog_api_key")
-	}
-
-	if b, ok := credentials[datadogApplicationKeySecretKey]; ok {
-		dd.applicationKey = string(b)
-	} else {
-		return nil, fmt.Errorf("datadog credentials does not contain datadog_application_key")
+	if b, ok := credentials[datadogNoDataValueSecretKey]; ok {
+		noDataValue, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", datadogNoDataValueSecretKey, err)
+		}
+		dd.noDataValue = &noDataValue
 	}
 
 	md, err := time.ParseDuration(metricInterval)
@@ -117,35 +240,41 @@ func (p *DatadogProvider) RunQuery(query string) (float64, error) {
 	q.Add("to", strconv.FormatInt(now, 10))
 	req.URL.RawQuery = q.Encode()
 
-	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
-	defer cancel()
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	b, statusCode, err := p.doRequest(req)
 	if err != nil {
-		return 0, fmt.Errorf("request failed: %w", err)
+		return 0, err
 	}
 
-	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		return 0, fmt.Errorf("error reading body: %w", err)
+	var res datadogResponse
+	unmarshalErr := json.Unmarshal(b, &res)
+
+	// Datadog responds with HTTP 200 for bad queries, rate limiting and scope
+	// issues, embedding the actual cause in the body instead of the status code.
+	if unmarshalErr == nil && res.Status == "error" {
+		return 0, fmt.Errorf("datadog error: %s", strings.Join(res.Errors, ", "))
 	}
 
-	if r.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("error response: %s: %w", string(b), err)
+	if statusCode != http.StatusOK {
+		return 0, fmt.Errorf("error response: %s", string(b))
 	}
 
-	var res datadogResponse
-	if err := json.Unmarshal(b, &res); err != nil {
-		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	if unmarshalErr != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", unmarshalErr, string(b))
 	}
 
 	if len(res.Series) < 1 {
+		if p.noDataValue != nil {
+			return *p.noDataValue, nil
+		}
 		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
 	}
 
 	// in case of more than one series in the response, pick the first time series from the response
 	pl := res.Series[0].Pointlist
 	if len(pl) < 1 {
+		if p.noDataValue != nil {
+			return *p.noDataValue, nil
+		}
 		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
 	}
 
@@ -153,6 +282,9 @@ func (p *DatadogProvider) RunQuery(query string) (float64, error) {
 	// must not pick the newest one from the end of the interval, since it almost always contains an incomplete bucket
 	vs := pl[0]
 	if len(vs) < 1 {
+		if p.noDataValue != nil {
+			return *p.noDataValue, nil
+		}
 		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
 	}
 
@@ -171,23 +303,75 @@ func (p *DatadogProvider) IsOnline() (bool, error) {
 	req.Header.Add(datadogAPIKeyHeaderKey, p.apiKey)
 	req.Header.Add(datadogApplicationKeyHeaderKey, p.applicationKey)
 
-	ctx, cancel := context.WithTimeout(req.Context(), p.timeout)
-	defer cancel()
-	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	b, statusCode, err := p.doRequest(req)
 	if err != nil {
-		return false, fmt.Errorf("request failed: %w", err)
+		return false, err
 	}
 
-	defer r.Body.Close()
+	if statusCode != http.StatusOK {
+		return false, fmt.Errorf("error response: %s", string(b))
+	}
 
-	b, err := io.ReadAll(r.Body)
-	if err != nil {
-		return false, fmt.Errorf("error reading body: %w", err)
+	return true, nil
+}
+
+// doRequest executes req against the shared datadogHTTPClient, retrying on 429 and
+// 5xx responses up to p.maxRetries times.
+func (p *DatadogProvider) doRequest(req *http.Request) ([]byte, int, error) {
+	return datadogDoRequest(req, p.timeout, p.maxRetries, p.retryBackoffCap)
+}
+
+// datadogDoRequest executes req against the shared datadogHTTPClient, retrying on 429
+// and 5xx responses up to maxRetries times. The wait between attempts honors Datadog's
+// Retry-After/X-RateLimit-Reset headers when present, and otherwise falls back to an
+// exponential backoff capped at retryBackoffCap.
+func datadogDoRequest(req *http.Request, timeout time.Duration, maxRetries int, retryBackoffCap time.Duration) ([]byte, int, error) {
+	backoff := datadogDefaultRetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		r, err := datadogHTTPClient.Do(req.Clone(ctx))
+		if err != nil {
+			cancel()
+			return nil, 0, fmt.Errorf("request failed: %w", err)
+		}
+
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		cancel()
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading body: %w", err)
+		}
+
+		throttledOrUnavailable := r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= http.StatusInternalServerError
+		if !throttledOrUnavailable || attempt >= maxRetries {
+			return b, r.StatusCode, nil
+		}
+
+		time.Sleep(datadogRetryWait(r.Header, backoff, retryBackoffCap))
+		backoff *= 2
+		if backoff > retryBackoffCap {
+			backoff = retryBackoffCap
+		}
 	}
+}
 
-	if r.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("error response: %s", string(b))
+// datadogRetryWait returns how long to wait before the next retry attempt, preferring
+// Datadog's Retry-After or X-RateLimit-Reset headers (both expressed in seconds) over
+// the exponential backoff, and never exceeding cap.
+func datadogRetryWait(h http.Header, backoff, cap time.Duration) time.Duration {
+	for _, header := range []string{"Retry-After", "X-RateLimit-Reset"} {
+		if v := h.Get(header); v != "" {
+			if secs, err := strconv.Atoi(v); err == nil {
+				if d := time.Duration(secs) * time.Second; d < cap {
+					return d
+				}
+				return cap
+			}
+		}
 	}
 
-	return true, nil
+	if backoff > cap {
+		return cap
+	}
+	return backoff
 }
@@ -0,0 +1,213 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// https://docs.datadoghq.com/api/latest/monitors/
+const (
+	datadogMonitorPath       = "/api/v1/monitor"
+	datadogMonitorSearchPath = "/api/v1/monitor/search"
+)
+
+// datadogMonitorHealth maps a monitor's overall_state to the numeric health signal
+// RunQuery returns: 0 for OK, 1 for anything that should fail the canary analysis.
+var datadogMonitorHealth = map[string]float64{
+	"OK":      0,
+	"Alert":   1,
+	"Warn":    1,
+	"No Data": 1,
+}
+
+type datadogMonitorResponse struct {
+	OverallState string `json:"overall_state"`
+}
+
+type datadogMonitorSearchResponse struct {
+	Monitors []struct {
+		OverallState string `json:"overall_state"`
+	} `json:"monitors"`
+}
+
+// DatadogMonitorProvider queries existing Datadog monitors and surfaces their
+// overall state as a numeric health signal, so canary analysis can gate on
+// SRE-owned monitors (SLO burn-rate monitors, composite monitors) instead of
+// re-encoding those conditions as raw metric queries.
+type DatadogMonitorProvider struct {
+	monitorEndpoint          string
+	monitorSearchEndpoint    string
+	apiKeyValidationEndpoint string
+
+	timeout         time.Duration
+	apiKey          string
+	applicationKey  string
+	maxRetries      int
+	retryBackoffCap time.Duration
+}
+
+// NewDatadogMonitorProvider takes a provider spec and the credentials map, and
+// returns a Datadog client ready to query monitor state against the API.
+func NewDatadogMonitorProvider(provider flaggerv1.MetricTemplateProvider,
+	credentials map[string][]byte) (*DatadogMonitorProvider, error) {
+
+	address, err := datadogResolveAddress(provider, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, applicationKey, err := datadogAuthFromCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries, retryBackoffCap, err := datadogRetrySettingsFromCredentials(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	dm := DatadogMonitorProvider{
+		timeout:                  5 * time.Second,
+		monitorEndpoint:          address + datadogMonitorPath,
+		monitorSearchEndpoint:    address + datadogMonitorSearchPath,
+		apiKeyValidationEndpoint: address + datadogAPIKeyValidationPath,
+		apiKey:                   apiKey,
+		applicationKey:           applicationKey,
+		maxRetries:               maxRetries,
+		retryBackoffCap:          retryBackoffCap,
+	}
+
+	return &dm, nil
+}
+
+// RunQuery interprets query as a Datadog monitor ID (e.g. "12345678"); if it is not
+// numeric, query is treated as a monitor search expression instead. It returns 0 when
+// the monitor (or every monitor matched by the search) is OK, and 1 when it is in
+// Alert, Warn or No Data. Any other monitor state is returned as an error (for a
+// search, only when it is met before a non-OK monitor), and so is a search that
+// matches no monitor.
+func (p *DatadogMonitorProvider) RunQuery(query string) (float64, error) {
+	if id, err := strconv.Atoi(query); err == nil {
+		return p.runMonitorQuery(id)
+	}
+	return p.runSearchQuery(query)
+}
+
+func (p *DatadogMonitorProvider) runMonitorQuery(id int) (float64, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%d", p.monitorEndpoint, id), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error http.NewRequest: %w", err)
+	}
+	p.setHeaders(req)
+
+	b, statusCode, err := p.doRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	if statusCode != http.StatusOK {
+		return 0, fmt.Errorf("error response: %s", string(b))
+	}
+
+	var res datadogMonitorResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	health, ok := datadogMonitorHealth[res.OverallState]
+	if !ok {
+		return 0, fmt.Errorf("unknown datadog monitor state: %s", res.OverallState)
+	}
+	return health, nil
+}
+
+func (p *DatadogMonitorProvider) runSearchQuery(query string) (float64, error) {
+	req, err := http.NewRequest("GET", p.monitorSearchEndpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error http.NewRequest: %w", err)
+	}
+	p.setHeaders(req)
+	q := req.URL.Query()
+	q.Add("query", query)
+	req.URL.RawQuery = q.Encode()
+
+	b, statusCode, err := p.doRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	if statusCode != http.StatusOK {
+		return 0, fmt.Errorf("error response: %s", string(b))
+	}
+
+	var res datadogMonitorSearchResponse
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("error unmarshaling result: %w, '%s'", err, string(b))
+	}
+
+	if len(res.Monitors) < 1 {
+		return 0, fmt.Errorf("invalid response: %s: %w", string(b), ErrNoValuesFound)
+	}
+
+	// the search matches a set of monitors: any one of them in a non-OK state
+	// is enough to fail the gate
+	for _, m := range res.Monitors {
+		health, ok := datadogMonitorHealth[m.OverallState]
+		if !ok {
+			return 0, fmt.Errorf("unknown datadog monitor state: %s", m.OverallState)
+		}
+		if health != 0 {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// IsOnline calls the Datadog's validation endpoint with api keys
+// and returns an error if the validation fails
+func (p *DatadogMonitorProvider) IsOnline() (bool, error) {
+	req, err := http.NewRequest("GET", p.apiKeyValidationEndpoint, nil)
+	if err != nil {
+		return false, fmt.Errorf("error http.NewRequest: %w", err)
+	}
+	p.setHeaders(req)
+
+	b, statusCode, err := p.doRequest(req)
+	if err != nil {
+		return false, err
+	}
+
+	if statusCode != http.StatusOK {
+		return false, fmt.Errorf("error response: %s", string(b))
+	}
+
+	return true, nil
+}
+
+func (p *DatadogMonitorProvider) setHeaders(req *http.Request) {
+	req.Header.Set(datadogAPIKeyHeaderKey, p.apiKey)
+	req.Header.Set(datadogApplicationKeyHeaderKey, p.applicationKey)
+}
+
+func (p *DatadogMonitorProvider) doRequest(req *http.Request) ([]byte, int, error) {
+	return datadogDoRequest(req, p.timeout, p.maxRetries, p.retryBackoffCap)
+}
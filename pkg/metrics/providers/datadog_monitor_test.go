@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newTestDatadogMonitorProvider(t *testing.T, address string) *DatadogMonitorProvider {
+	credentials := map[string][]byte{
+		datadogAPIKeySecretKey:         []byte("api-key"),
+		datadogApplicationKeySecretKey: []byte("application-key"),
+	}
+
+	provider, err := NewDatadogMonitorProvider(flaggerv1.MetricTemplateProvider{Address: address}, credentials)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestDatadogMonitorProvider_RunQuery_ByID(t *testing.T) {
+	for _, tc := range []struct {
+		state   string
+		want    float64
+		wantErr bool
+	}{
+		{state: "OK", want: 0},
+		{state: "Alert", want: 1},
+		{state: "Warn", want: 1},
+		{state: "No Data", want: 1},
+		{state: "Skipped", wantErr: true},
+	} {
+		t.Run(tc.state, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v1/monitor/12345678", r.URL.Path)
+				fmt.Fprintf(w, `{"overall_state":%q}`, tc.state)
+			}))
+			defer ts.Close()
+
+			provider := newTestDatadogMonitorProvider(t, ts.URL)
+
+			val, err := provider.RunQuery("12345678")
+			if tc.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown datadog monitor state")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, val)
+		})
+	}
+}
+
+func TestDatadogMonitorProvider_RunQuery_Search(t *testing.T) {
+	t.Run("all matched monitors OK", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v1/monitor/search", r.URL.Path)
+			w.Write([]byte(`{"monitors":[{"overall_state":"OK"},{"overall_state":"OK"}]}`))
+		}))
+		defer ts.Close()
+
+		provider := newTestDatadogMonitorProvider(t, ts.URL)
+
+		val, err := provider.RunQuery("tag:canary")
+		require.NoError(t, err)
+		assert.Equal(t, float64(0), val)
+	})
+
+	t.Run("one matched monitor not OK fails the gate", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"monitors":[{"overall_state":"OK"},{"overall_state":"Alert"}]}`))
+		}))
+		defer ts.Close()
+
+		provider := newTestDatadogMonitorProvider(t, ts.URL)
+
+		val, err := provider.RunQuery("tag:canary")
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), val)
+	})
+
+	t.Run("no matched monitors returns ErrNoValuesFound", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"monitors":[]}`))
+		}))
+		defer ts.Close()
+
+		provider := newTestDatadogMonitorProvider(t, ts.URL)
+
+		_, err := provider.RunQuery("tag:canary")
+		assert.ErrorIs(t, err, ErrNoValuesFound)
+	})
+
+	t.Run("unknown monitor state errors", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"monitors":[{"overall_state":"Ignored"}]}`))
+		}))
+		defer ts.Close()
+
+		provider := newTestDatadogMonitorProvider(t, ts.URL)
+
+		_, err := provider.RunQuery("tag:canary")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown datadog monitor state")
+	})
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+func newTestDatadogProvider(t *testing.T, address string, extraCredentials map[string][]byte) *DatadogProvider {
+	credentials := map[string][]byte{
+		datadogAPIKeySecretKey:         []byte("api-key"),
+		datadogApplicationKeySecretKey: []byte("application-key"),
+	}
+	for k, v := range extraCredentials {
+		credentials[k] = v
+	}
+
+	provider, err := NewDatadogProvider("1m", flaggerv1.MetricTemplateProvider{Address: address}, credentials)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestDatadogProvider_RunQuery_NoSeries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"series":[]}`))
+	}))
+	defer ts.Close()
+
+	t.Run("without noDataValue returns ErrNoValuesFound", func(t *testing.T) {
+		provider := newTestDatadogProvider(t, ts.URL, nil)
+
+		_, err := provider.RunQuery("up")
+		assert.ErrorIs(t, err, ErrNoValuesFound)
+	})
+
+	t.Run("with noDataValue returns the configured fallback", func(t *testing.T) {
+		provider := newTestDatadogProvider(t, ts.URL, map[string][]byte{
+			datadogNoDataValueSecretKey: []byte("100"),
+		})
+
+		val, err := provider.RunQuery("up")
+		require.NoError(t, err)
+		assert.Equal(t, float64(100), val)
+	})
+}
+
+func TestDatadogProvider_RunQuery_EmptyPointlist(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"series":[{"pointlist":[]}]}`))
+	}))
+	defer ts.Close()
+
+	t.Run("without noDataValue returns ErrNoValuesFound", func(t *testing.T) {
+		provider := newTestDatadogProvider(t, ts.URL, nil)
+
+		_, err := provider.RunQuery("up")
+		assert.ErrorIs(t, err, ErrNoValuesFound)
+	})
+
+	t.Run("with noDataValue returns the configured fallback", func(t *testing.T) {
+		provider := newTestDatadogProvider(t, ts.URL, map[string][]byte{
+			datadogNoDataValueSecretKey: []byte("0"),
+		})
+
+		val, err := provider.RunQuery("up")
+		require.NoError(t, err)
+		assert.Equal(t, float64(0), val)
+	})
+}
+
+func TestDatadogProvider_RunQuery_ErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Datadog responds with HTTP 200 even for bad queries, rate limiting and scope issues.
+		w.Write([]byte(`{"status":"error","errors":["rate limit exceeded"]}`))
+	}))
+	defer ts.Close()
+
+	provider := newTestDatadogProvider(t, ts.URL, nil)
+
+	_, err := provider.RunQuery("up")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limit exceeded")
+}
+
+func TestDatadogDoRequest_RetriesOnThrottleThenSucceeds(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("rate limited"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	require.NoError(t, err)
+
+	b, statusCode, err := datadogDoRequest(req, 5*time.Second, 3, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "ok", string(b))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&attempts))
+}
+
+func TestDatadogDoRequest_ExhaustsRetries(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("still unavailable"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	require.NoError(t, err)
+
+	b, statusCode, err := datadogDoRequest(req, 5*time.Second, 2, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, "still unavailable", string(b))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&attempts)) // initial attempt + 2 retries
+}
+
+func TestDatadogRetryWait(t *testing.T) {
+	t.Run("honors Retry-After over the backoff", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Retry-After", "2")
+		assert.Equal(t, 2*time.Second, datadogRetryWait(h, 500*time.Millisecond, 30*time.Second))
+	})
+
+	t.Run("falls back to the exponential backoff without headers", func(t *testing.T) {
+		assert.Equal(t, 500*time.Millisecond, datadogRetryWait(http.Header{}, 500*time.Millisecond, 30*time.Second))
+	})
+
+	t.Run("caps the wait at retryBackoffCap", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("X-RateLimit-Reset", "120")
+		assert.Equal(t, 30*time.Second, datadogRetryWait(h, 500*time.Millisecond, 30*time.Second))
+	})
+}
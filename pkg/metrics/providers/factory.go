@@ -0,0 +1,51 @@
+/*
+Copyright 2020 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providers
+
+import (
+	"fmt"
+
+	flaggerv1 "github.com/fluxcd/flagger/pkg/apis/flagger/v1beta1"
+)
+
+// datadogMonitorProviderType is the MetricTemplate provider type that gates on an
+// existing Datadog monitor's state, as opposed to "datadog" which runs a metrics query.
+const datadogMonitorProviderType = "datadogmonitor"
+
+// Interface must be implemented by all metric providers
+type Interface interface {
+	// RunQuery executes the query and returns the result
+	RunQuery(query string) (float64, error)
+	// IsOnline returns true if the metrics provider is reachable
+	IsOnline() (bool, error)
+}
+
+// NewProvider returns an Interface implementation for the given provider spec, or an
+// error if the provider type is not supported.
+func NewProvider(metricInterval string,
+	provider flaggerv1.MetricTemplateProvider,
+	credentials map[string][]byte) (Interface, error) {
+
+	switch provider.Type {
+	case "datadog":
+		return NewDatadogProvider(metricInterval, provider, credentials)
+	case datadogMonitorProviderType:
+		return NewDatadogMonitorProvider(provider, credentials)
+	default:
+		return nil, fmt.Errorf("metrics provider %s not supported", provider.Type)
+	}
+}